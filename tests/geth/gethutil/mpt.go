@@ -4,17 +4,22 @@ import (
 	"bytes"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/trie"
+	"github.com/holiman/uint256"
 )
 
 type Transaction struct {
 	Essence struct {
 		Eip1559 *EIP1559Transaction `json:"Eip1559"`
+		Eip2930 *EIP2930Transaction `json:"Eip2930"`
+		Eip4844 *EIP4844Transaction `json:"Eip4844"`
 		Legacy  *LegacyTransaction  `json:"Legacy"`
 	} `json:"essence"`
 	Signature struct {
@@ -24,16 +29,46 @@ type Transaction struct {
 	} `json:"signature"`
 }
 
+type AccessTuple struct {
+	Address     common.Address `json:"address"`
+	StorageKeys []common.Hash  `json:"storage_keys"`
+}
+
 type EIP1559Transaction struct {
-	AccessList           []common.Address `json:"access_list"`
-	ChainId              int64            `json:"chain_id"`
-	Data                 hexutil.Bytes    `json:"data"`
-	GasLimit             hexutil.Uint64   `json:"gas_limit"`
-	MaxFeePerGas         *hexutil.Big     `json:"max_fee_per_gas"`
-	MaxPriorityFeePerGas *hexutil.Big     `json:"max_priority_fee_per_gas"`
-	To                   To               `json:"to"`
-	Nonce                uint64           `json:"nonce"`
-	Value                *hexutil.Big     `json:"value"`
+	AccessList           []AccessTuple  `json:"access_list"`
+	ChainId              int64          `json:"chain_id"`
+	Data                 hexutil.Bytes  `json:"data"`
+	GasLimit             hexutil.Uint64 `json:"gas_limit"`
+	MaxFeePerGas         *hexutil.Big   `json:"max_fee_per_gas"`
+	MaxPriorityFeePerGas *hexutil.Big   `json:"max_priority_fee_per_gas"`
+	To                   To             `json:"to"`
+	Nonce                uint64         `json:"nonce"`
+	Value                *hexutil.Big   `json:"value"`
+}
+
+type EIP2930Transaction struct {
+	AccessList []AccessTuple  `json:"access_list"`
+	ChainId    int64          `json:"chain_id"`
+	Data       hexutil.Bytes  `json:"data"`
+	GasLimit   hexutil.Uint64 `json:"gas_limit"`
+	GasPrice   *hexutil.Big   `json:"gas_price"`
+	To         To             `json:"to"`
+	Nonce      uint64         `json:"nonce"`
+	Value      *hexutil.Big   `json:"value"`
+}
+
+type EIP4844Transaction struct {
+	AccessList           []AccessTuple  `json:"access_list"`
+	BlobVersionedHashes  []common.Hash  `json:"blob_versioned_hashes"`
+	ChainId              int64          `json:"chain_id"`
+	Data                 hexutil.Bytes  `json:"data"`
+	GasLimit             hexutil.Uint64 `json:"gas_limit"`
+	MaxFeePerBlobGas     *hexutil.Big   `json:"max_fee_per_blob_gas"`
+	MaxFeePerGas         *hexutil.Big   `json:"max_fee_per_gas"`
+	MaxPriorityFeePerGas *hexutil.Big   `json:"max_priority_fee_per_gas"`
+	To                   To             `json:"to"`
+	Nonce                uint64         `json:"nonce"`
+	Value                *hexutil.Big   `json:"value"`
 }
 
 type LegacyTransaction struct {
@@ -69,50 +104,283 @@ func (t *To) UnmarshalJSON(data []byte) error {
 }
 
 type Result struct {
-	Root common.Hash `json:"root"`
-	Rlps []string    `json:"rlps"`
+	Root  common.Hash `json:"root"`
+	Rlps  []string    `json:"rlps"`
+	Types []uint8     `json:"types"`
+}
+
+type Withdrawal struct {
+	Index          uint64         `json:"index"`
+	ValidatorIndex uint64         `json:"validator_index"`
+	Address        common.Address `json:"address"`
+	Amount         uint64         `json:"amount"`
+}
+
+// Header carries the subset of a block header needed to check the
+// transactions/receipts/withdrawals roots BlockRoots derives against the
+// roots the block actually claims.
+type Header struct {
+	TransactionsRoot common.Hash  `json:"transactions_root"`
+	ReceiptsRoot     common.Hash  `json:"receipts_root"`
+	WithdrawalsRoot  *common.Hash `json:"withdrawals_root"`
+}
+
+// BlockRootsResult is the result of BlockRoots: the transactions root and
+// RLPs as returned by MptRoot, plus the receipts root and the Shanghai
+// withdrawals root.
+type BlockRootsResult struct {
+	Result
+	ReceiptsRoot    common.Hash `json:"receipts_root"`
+	ReceiptsRlps    []string    `json:"receipts_rlps"`
+	ReceiptsTypes   []uint8     `json:"receipts_types"`
+	WithdrawalsRoot common.Hash `json:"withdrawals_root"`
+	WithdrawalsRlps []string    `json:"withdrawals_rlps"`
+}
+
+// toAccessList converts the JSON-friendly access list representation into
+// the go-ethereum type used to build typed transactions.
+func toAccessList(tuples []AccessTuple) types.AccessList {
+	accessList := make(types.AccessList, len(tuples))
+	for i, tuple := range tuples {
+		accessList[i] = types.AccessTuple{
+			Address:     tuple.Address,
+			StorageKeys: tuple.StorageKeys,
+		}
+	}
+	return accessList
+}
+
+// toGethTx converts a Transaction into the corresponding go-ethereum typed
+// transaction, picking the variant based on which Essence field is set.
+func toGethTx(tx Transaction) (*types.Transaction, error) {
+	if tx.Essence.Eip1559 != nil {
+		return types.NewTx(&types.DynamicFeeTx{
+			ChainID:    big.NewInt(tx.Essence.Eip1559.ChainId),
+			Nonce:      tx.Essence.Eip1559.Nonce,
+			GasTipCap:  tx.Essence.Eip1559.MaxPriorityFeePerGas.ToInt(),
+			GasFeeCap:  tx.Essence.Eip1559.MaxFeePerGas.ToInt(),
+			Gas:        uint64(tx.Essence.Eip1559.GasLimit),
+			Value:      tx.Essence.Eip1559.Value.ToInt(),
+			Data:       tx.Essence.Eip1559.Data,
+			To:         tx.Essence.Eip1559.To.Call,
+			AccessList: toAccessList(tx.Essence.Eip1559.AccessList),
+			V:          big.NewInt(tx.Signature.V),
+			R:          tx.Signature.R.ToInt(),
+			S:          tx.Signature.S.ToInt(),
+		}), nil
+	} else if tx.Essence.Eip2930 != nil {
+		return types.NewTx(&types.AccessListTx{
+			ChainID:    big.NewInt(tx.Essence.Eip2930.ChainId),
+			Nonce:      tx.Essence.Eip2930.Nonce,
+			GasPrice:   tx.Essence.Eip2930.GasPrice.ToInt(),
+			Gas:        uint64(tx.Essence.Eip2930.GasLimit),
+			Value:      tx.Essence.Eip2930.Value.ToInt(),
+			Data:       tx.Essence.Eip2930.Data,
+			To:         tx.Essence.Eip2930.To.Call,
+			AccessList: toAccessList(tx.Essence.Eip2930.AccessList),
+			V:          big.NewInt(tx.Signature.V),
+			R:          tx.Signature.R.ToInt(),
+			S:          tx.Signature.S.ToInt(),
+		}), nil
+	} else if tx.Essence.Eip4844 != nil {
+		if tx.Essence.Eip4844.To.Call == nil {
+			return nil, fmt.Errorf("blob transactions cannot be contract creations")
+		}
+		return types.NewTx(&types.BlobTx{
+			ChainID:    uint256.MustFromBig(big.NewInt(tx.Essence.Eip4844.ChainId)),
+			Nonce:      tx.Essence.Eip4844.Nonce,
+			GasTipCap:  uint256.MustFromBig(tx.Essence.Eip4844.MaxPriorityFeePerGas.ToInt()),
+			GasFeeCap:  uint256.MustFromBig(tx.Essence.Eip4844.MaxFeePerGas.ToInt()),
+			Gas:        uint64(tx.Essence.Eip4844.GasLimit),
+			To:         *tx.Essence.Eip4844.To.Call,
+			Value:      uint256.MustFromBig(tx.Essence.Eip4844.Value.ToInt()),
+			Data:       tx.Essence.Eip4844.Data,
+			AccessList: toAccessList(tx.Essence.Eip4844.AccessList),
+			BlobFeeCap: uint256.MustFromBig(tx.Essence.Eip4844.MaxFeePerBlobGas.ToInt()),
+			BlobHashes: tx.Essence.Eip4844.BlobVersionedHashes,
+			V:          uint256.MustFromBig(big.NewInt(tx.Signature.V)),
+			R:          uint256.MustFromBig(tx.Signature.R.ToInt()),
+			S:          uint256.MustFromBig(tx.Signature.S.ToInt()),
+		}), nil
+	}
+	return types.NewTx(&types.LegacyTx{
+		Nonce:    tx.Essence.Legacy.Nonce,
+		GasPrice: tx.Essence.Legacy.GasPrice.ToInt(),
+		Gas:      uint64(tx.Essence.Legacy.GasLimit),
+		Value:    tx.Essence.Legacy.Value.ToInt(),
+		Data:     tx.Essence.Legacy.Data,
+		To:       tx.Essence.Legacy.To.Call,
+		V:        big.NewInt(tx.Signature.V),
+		R:        tx.Signature.R.ToInt(),
+		S:        tx.Signature.S.ToInt(),
+	}), nil
 }
 
 func MptRoot(txs []Transaction) *Result {
 	var txs2 []*types.Transaction
 	result := &Result{}
 	for _, tx := range txs {
-		var tx2 *types.Transaction
-		if tx.Essence.Eip1559 != nil {
-			tx2 = types.NewTx(&types.DynamicFeeTx{
-				ChainID:    big.NewInt(tx.Essence.Eip1559.ChainId),
-				Nonce:      tx.Essence.Eip1559.Nonce,
-				GasTipCap:  tx.Essence.Eip1559.MaxPriorityFeePerGas.ToInt(),
-				GasFeeCap:  tx.Essence.Eip1559.MaxFeePerGas.ToInt(),
-				Gas:        uint64(tx.Essence.Eip1559.GasLimit),
-				Value:      tx.Essence.Eip1559.Value.ToInt(),
-				Data:       tx.Essence.Eip1559.Data,
-				To:         tx.Essence.Eip1559.To.Call,
-				AccessList: []types.AccessTuple{},
-				V:          big.NewInt(tx.Signature.V),
-				R:          tx.Signature.R.ToInt(),
-				S:          tx.Signature.S.ToInt(),
-			})
-		} else {
-			tx2 = types.NewTx(&types.LegacyTx{
-				Nonce:    tx.Essence.Legacy.Nonce,
-				GasPrice: tx.Essence.Legacy.GasPrice.ToInt(),
-				Gas:      uint64(tx.Essence.Legacy.GasLimit),
-				Value:    tx.Essence.Legacy.Value.ToInt(),
-				Data:     tx.Essence.Legacy.Data,
-				To:       tx.Essence.Legacy.To.Call,
-				V:        big.NewInt(tx.Signature.V),
-				R:        tx.Signature.R.ToInt(),
-				S:        tx.Signature.S.ToInt(),
-			})
+		tx2, err := toGethTx(tx)
+		if err != nil {
+			panic(err)
 		}
 
-		var buf bytes.Buffer
-		tx2.EncodeRLP(&buf)
-		result.Rlps = append(result.Rlps, hex.EncodeToString(buf.Bytes()))
+		bin, err := tx2.MarshalBinary()
+		if err != nil {
+			panic(err)
+		}
+		result.Rlps = append(result.Rlps, hex.EncodeToString(bin))
+		result.Types = append(result.Types, tx2.Type())
 		txs2 = append(txs2, tx2)
 	}
 	root := types.DeriveSha(types.Transactions(txs2), trie.NewStackTrie(nil))
 	result.Root = root
 	return result
 }
+
+// RecoverSendersResult is the result of RecoverSenders: the EIP-2718 signing
+// hash and recovered sender for each transaction, in input order.
+type RecoverSendersResult struct {
+	Hashes []common.Hash    `json:"hashes"`
+	Froms  []common.Address `json:"froms"`
+}
+
+// RecoverSenders recovers the sender and signing hash of each transaction by
+// picking the correct signer for its type via types.LatestSignerForChainID
+// (EIP-155 for legacy, EIP-2930 for type-1, London for type-2, Cancun for
+// type-3); legacy transactions without replay protection use the Homestead
+// signer instead, so that zeth's guest no longer has to re-implement EIP-2718
+// signing-hash derivation.
+func RecoverSenders(txs []Transaction) (*RecoverSendersResult, error) {
+	result := &RecoverSendersResult{}
+	for i, tx := range txs {
+		tx2, err := toGethTx(tx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build tx %d: %w", i, err)
+		}
+		signer := types.LatestSignerForChainID(tx2.ChainId())
+		if !tx2.Protected() {
+			signer = types.HomesteadSigner{}
+		}
+		from, err := types.Sender(signer, tx2)
+		if err != nil {
+			return nil, fmt.Errorf("failed to recover sender of tx %d: %w", i, err)
+		}
+		result.Hashes = append(result.Hashes, signer.Hash(tx2))
+		result.Froms = append(result.Froms, from)
+	}
+	return result, nil
+}
+
+// BlockRoots derives the transactions root (see MptRoot), the receipts root
+// (see ReceiptsRoot) and the Shanghai withdrawals root (see WithdrawalsRoot),
+// and checks each against the roots header claims, so that Cancun/Shapella
+// blocks can be fully verified in zeth.
+func BlockRoots(header Header, txs []Transaction, receipts []Receipt, withdrawals []Withdrawal) (*BlockRootsResult, error) {
+	result := &BlockRootsResult{Result: *MptRoot(txs)}
+	if result.Root != header.TransactionsRoot {
+		return nil, fmt.Errorf("transactions root mismatch: got %s, want %s", result.Root, header.TransactionsRoot)
+	}
+
+	receiptsResult := ReceiptsRoot(receipts)
+	if receiptsResult.Root != header.ReceiptsRoot {
+		return nil, fmt.Errorf("receipts root mismatch: got %s, want %s", receiptsResult.Root, header.ReceiptsRoot)
+	}
+	result.ReceiptsRoot = receiptsResult.Root
+	result.ReceiptsRlps = receiptsResult.Rlps
+	result.ReceiptsTypes = receiptsResult.Types
+
+	withdrawalsResult := WithdrawalsRoot(withdrawals)
+	if header.WithdrawalsRoot != nil && withdrawalsResult.Root != *header.WithdrawalsRoot {
+		return nil, fmt.Errorf("withdrawals root mismatch: got %s, want %s", withdrawalsResult.Root, *header.WithdrawalsRoot)
+	}
+	result.WithdrawalsRoot = withdrawalsResult.Root
+	result.WithdrawalsRlps = withdrawalsResult.Rlps
+
+	return result, nil
+}
+
+// WithdrawalsRoot recomputes the Shanghai withdrawals root via the same
+// types.DeriveSha(..., trie.NewStackTrie(nil)) path geth uses.
+func WithdrawalsRoot(withdrawals []Withdrawal) *Result {
+	result := &Result{}
+	var withdrawals2 types.Withdrawals
+	for _, w := range withdrawals {
+		w2 := &types.Withdrawal{
+			Index:     w.Index,
+			Validator: w.ValidatorIndex,
+			Address:   w.Address,
+			Amount:    w.Amount,
+		}
+		bin, err := rlpBytes(w2)
+		if err != nil {
+			panic(err)
+		}
+		result.Rlps = append(result.Rlps, hex.EncodeToString(bin))
+		withdrawals2 = append(withdrawals2, w2)
+	}
+	result.Root = types.DeriveSha(withdrawals2, trie.NewStackTrie(nil))
+	return result
+}
+
+type Log struct {
+	Address common.Address `json:"address"`
+	Topics  []common.Hash  `json:"topics"`
+	Data    hexutil.Bytes  `json:"data"`
+}
+
+type Receipt struct {
+	Type              uint8         `json:"type"`
+	PostState         hexutil.Bytes `json:"post_state"`
+	Status            *uint64       `json:"status"`
+	CumulativeGasUsed uint64        `json:"cumulative_gas_used"`
+	Bloom             hexutil.Bytes `json:"bloom"`
+	Logs              []Log         `json:"logs"`
+}
+
+// ReceiptsRoot recomputes the receipts root via the same
+// types.DeriveSha(..., trie.NewStackTrie(nil)) path geth uses, preserving
+// the typed-receipt envelopes introduced by EIP-2930/1559/4844.
+func ReceiptsRoot(receipts []Receipt) *Result {
+	result := &Result{}
+	var receipts2 types.Receipts
+	for _, r := range receipts {
+		r2 := &types.Receipt{
+			Type:              r.Type,
+			CumulativeGasUsed: r.CumulativeGasUsed,
+			Bloom:             types.BytesToBloom(r.Bloom),
+		}
+		if r.PostState != nil {
+			r2.PostState = r.PostState
+		} else if r.Status != nil {
+			r2.Status = *r.Status
+		}
+		r2.Logs = make([]*types.Log, len(r.Logs))
+		for i, l := range r.Logs {
+			r2.Logs[i] = &types.Log{
+				Address: l.Address,
+				Topics:  l.Topics,
+				Data:    l.Data,
+			}
+		}
+
+		bin, err := r2.MarshalBinary()
+		if err != nil {
+			panic(err)
+		}
+		result.Rlps = append(result.Rlps, hex.EncodeToString(bin))
+		result.Types = append(result.Types, r2.Type)
+		receipts2 = append(receipts2, r2)
+	}
+	result.Root = types.DeriveSha(receipts2, trie.NewStackTrie(nil))
+	return result
+}
+
+// rlpBytes RLP-encodes v using the standard go-ethereum encoder.
+func rlpBytes(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := rlp.Encode(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -28,6 +28,87 @@ func MptRoot(configStr *C.char) *C.char {
 	return C.CString(string(bytes))
 }
 
+//export ReceiptsRoot
+func ReceiptsRoot(configStr *C.char) *C.char {
+	var receipts []gethutil.Receipt
+	err := json.Unmarshal([]byte(C.GoString(configStr)), &receipts)
+	if err != nil {
+		return C.CString(fmt.Sprintf("Failed to unmarshal receipts, err: %v", err))
+	}
+
+	executionResult := gethutil.ReceiptsRoot(receipts)
+	bytes, err := json.MarshalIndent(executionResult, "", "  ")
+	if err != nil {
+		return C.CString(fmt.Sprintf("Failed to marshal Result, err: %v", err))
+	}
+
+	return C.CString(string(bytes))
+}
+
+//export WithdrawalsRoot
+func WithdrawalsRoot(configStr *C.char) *C.char {
+	var withdrawals []gethutil.Withdrawal
+	err := json.Unmarshal([]byte(C.GoString(configStr)), &withdrawals)
+	if err != nil {
+		return C.CString(fmt.Sprintf("Failed to unmarshal withdrawals, err: %v", err))
+	}
+
+	executionResult := gethutil.WithdrawalsRoot(withdrawals)
+	bytes, err := json.MarshalIndent(executionResult, "", "  ")
+	if err != nil {
+		return C.CString(fmt.Sprintf("Failed to marshal Result, err: %v", err))
+	}
+
+	return C.CString(string(bytes))
+}
+
+//export RecoverSenders
+func RecoverSenders(configStr *C.char) *C.char {
+	var txs []gethutil.Transaction
+	err := json.Unmarshal([]byte(C.GoString(configStr)), &txs)
+	if err != nil {
+		return C.CString(fmt.Sprintf("Failed to unmarshal txs, err: %v", err))
+	}
+
+	executionResult, err := gethutil.RecoverSenders(txs)
+	if err != nil {
+		return C.CString(fmt.Sprintf("Failed to recover senders, err: %v", err))
+	}
+
+	bytes, err := json.MarshalIndent(executionResult, "", "  ")
+	if err != nil {
+		return C.CString(fmt.Sprintf("Failed to marshal RecoverSendersResult, err: %v", err))
+	}
+
+	return C.CString(string(bytes))
+}
+
+//export BlockRoots
+func BlockRoots(configStr *C.char) *C.char {
+	var config struct {
+		Header      gethutil.Header        `json:"header"`
+		Txs         []gethutil.Transaction `json:"txs"`
+		Receipts    []gethutil.Receipt     `json:"receipts"`
+		Withdrawals []gethutil.Withdrawal  `json:"withdrawals"`
+	}
+	err := json.Unmarshal([]byte(C.GoString(configStr)), &config)
+	if err != nil {
+		return C.CString(fmt.Sprintf("Failed to unmarshal config, err: %v", err))
+	}
+
+	executionResult, err := gethutil.BlockRoots(config.Header, config.Txs, config.Receipts, config.Withdrawals)
+	if err != nil {
+		return C.CString(fmt.Sprintf("Failed to derive block roots, err: %v", err))
+	}
+
+	bytes, err := json.MarshalIndent(executionResult, "", "  ")
+	if err != nil {
+		return C.CString(fmt.Sprintf("Failed to marshal BlockRootsResult, err: %v", err))
+	}
+
+	return C.CString(string(bytes))
+}
+
 //export FreeString
 func FreeString(str *C.char) {
 	C.free(unsafe.Pointer(str))